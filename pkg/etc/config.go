@@ -0,0 +1,124 @@
+package etc
+
+import "time"
+
+// Mode represents the mode in which the Trivy wrapper drives the trivy
+// binary.
+type Mode string
+
+const (
+	// ModeStandalone execs trivy against a local vulnerability DB, downloading
+	// it into CacheDir on first use.
+	ModeStandalone Mode = "Standalone"
+	// ModeClientServer delegates scanning to a shared `trivy server` instance
+	// so the local vulnerability DB isn't downloaded/stored by every replica.
+	ModeClientServer Mode = "ClientServer"
+)
+
+// Trivy holds configuration of the Trivy wrapper.
+type Trivy struct {
+	CacheDir      string `env:"TRIVY_CACHE_DIR" envDefault:"/home/scanner/.cache/trivy"`
+	ReportsDir    string `env:"TRIVY_REPORTS_DIR" envDefault:"/home/scanner/.cache/reports"`
+	DebugMode     bool   `env:"TRIVY_DEBUG_MODE" envDefault:"false"`
+	VulnType      string `env:"TRIVY_VULN_TYPE" envDefault:"os,library"`
+	Severity      string `env:"TRIVY_SEVERITY" envDefault:"UNKNOWN,LOW,MEDIUM,HIGH,CRITICAL"`
+	IgnoreUnfixed bool   `env:"TRIVY_IGNORE_UNFIXED" envDefault:"false"`
+
+	// Mode selects how the wrapper talks to Trivy. Defaults to Standalone.
+	Mode Mode `env:"TRIVY_MODE" envDefault:"Standalone"`
+
+	// ServerURL is the address of the `trivy server` instance used in
+	// ModeClientServer, e.g. http://trivy.trivy:4954.
+	ServerURL string `env:"TRIVY_SERVER_URL"`
+	// ServerToken is sent to the Trivy server to authenticate the client.
+	ServerToken string `env:"TRIVY_SERVER_TOKEN"`
+	// ServerTokenHeader is the HTTP header used to carry ServerToken.
+	ServerTokenHeader string `env:"TRIVY_SERVER_TOKEN_HEADER" envDefault:"Trivy-Token"`
+	// ServerCustomHeaders are additional headers sent with every request to
+	// the Trivy server, e.g. required by an authenticating reverse proxy.
+	ServerCustomHeaders []string `env:"TRIVY_SERVER_CUSTOM_HEADERS" envSeparator:","`
+
+	// InsecureRegistries is the set of registry hostnames (host[:port]) that
+	// should be scanned without verifying TLS certificates, e.g. a registry
+	// serving a self-signed cert. Unlike a single global
+	// insecure switch, only images from these hosts are affected.
+	InsecureRegistries []string `env:"TRIVY_INSECURE_REGISTRIES" envSeparator:","`
+	// NonSSLRegistries is the set of registry hostnames that should be
+	// scanned over plain HTTP rather than HTTPS.
+	NonSSLRegistries []string `env:"TRIVY_NON_SSL_REGISTRIES" envSeparator:","`
+	// CustomCAFile is the path to a PEM bundle of additional CA certificates
+	// trusted when connecting to registries, e.g. a CA file mounted from a
+	// Kubernetes Secret.
+	CustomCAFile string `env:"TRIVY_CUSTOM_CA_FILE"`
+	// CustomCA is an inline PEM-encoded CA bundle, used instead of
+	// CustomCAFile when the certificate isn't mounted as a file, e.g. it was
+	// passed directly as an environment variable.
+	CustomCA string `env:"TRIVY_CUSTOM_CA"`
+
+	// SkipDirs is passed through to trivy's --skip-dirs, excluding the given
+	// paths from the scan.
+	SkipDirs []string `env:"TRIVY_SKIP_DIRS" envSeparator:","`
+	// SkipFiles is passed through to trivy's --skip-files, excluding the
+	// given files from the scan.
+	SkipFiles []string `env:"TRIVY_SKIP_FILES" envSeparator:","`
+	// DisabledAnalyzers is passed through to trivy's --disabled-analyzers,
+	// e.g. to turn off secret scanning or a language/OS analyzer that isn't
+	// relevant to this deployment.
+	DisabledAnalyzers []string `env:"TRIVY_DISABLED_ANALYZERS" envSeparator:","`
+	// ReportFormat names one of trivy's --format values, e.g. "json"
+	// (default), "spdx", "spdx-json", "cyclonedx" or "cyclonedx-json". The
+	// trivy backend does not read it: Run always requests "json", and RunSBOM
+	// uses the format passed to it.
+	ReportFormat string `env:"TRIVY_REPORT_FORMAT" envDefault:"json"`
+
+	// Concurrency bounds how many scans run at once. Each concurrent scan is
+	// given its own cache subdirectory so scans never share a single
+	// BoltDB-backed vuln DB handle.
+	Concurrency int `env:"TRIVY_CONCURRENCY" envDefault:"1"`
+	// DBUpdateInterval is how often the background updater refreshes the
+	// canonical vuln DB shared by all workers.
+	DBUpdateInterval time.Duration `env:"TRIVY_DB_UPDATE_INTERVAL" envDefault:"1h"`
+
+	// Runtime selects how the wrapper drives Trivy. Defaults to RuntimeBinary.
+	Runtime Runtime `env:"TRIVY_RUNTIME" envDefault:"binary"`
+}
+
+// Runtime selects how the Wrapper drives Trivy: by exec-ing the CLI binary,
+// or in-process as a Go library.
+type Runtime string
+
+const (
+	// RuntimeBinary execs the trivy CLI. It's the default, and lets
+	// operators pin the exact Trivy CLI version they deploy independently of
+	// this scanner's Go module graph.
+	RuntimeBinary Runtime = "binary"
+	// RuntimeLibrary drives Trivy in-process via fanal/scanner, avoiding
+	// fork/exec overhead per scan.
+	RuntimeLibrary Runtime = "library"
+)
+
+// IsSBOMFormat reports whether format is one of trivy's SBOM output formats,
+// as opposed to its native vulnerability JSON report.
+func IsSBOMFormat(format string) bool {
+	switch format {
+	case "spdx", "spdx-json", "cyclonedx", "cyclonedx-json":
+		return true
+	default:
+		return false
+	}
+}
+
+// Scanner selects and configures the pluggable vulnerability-scanner
+// backend this deployment runs.
+type Scanner struct {
+	// Backend is the name a backend was registered under with
+	// scanner.Register, e.g. "trivy" (default) or "grype".
+	Backend string `env:"SCANNER_BACKEND" envDefault:"trivy"`
+}
+
+// Grype holds configuration of the Grype backend.
+type Grype struct {
+	// IgnoreUnfixed passes --only-fixed to grype, dropping vulnerabilities
+	// that don't yet have a fix available.
+	IgnoreUnfixed bool `env:"GRYPE_IGNORE_UNFIXED" envDefault:"false"`
+}
@@ -0,0 +1,44 @@
+package scanner
+
+import "golang.org/x/xerrors"
+
+// Backend is implemented by every vulnerability-scanner integration (Trivy,
+// Grype, Clair, ...), so the HTTP adapter can scan with whichever one
+// etc.Scanner.Backend selects without depending on any one of them
+// directly.
+type Backend interface {
+	Run(imageRef string, auth Auth, opts Options) (Report, error)
+}
+
+// SBOMProvider is implemented by backends that can also produce an SBOM
+// document for an image, alongside the Harbor-native Report every Backend
+// returns from Run. Not every backend supports this (grype only speaks
+// vulnerabilities), so it's kept off Backend itself; callers that need SBOM
+// output should type-assert a Backend to SBOMProvider rather than require it
+// unconditionally.
+type SBOMProvider interface {
+	RunSBOM(imageRef string, auth Auth, format string) ([]byte, error)
+}
+
+// Factory constructs a Backend. This package doesn't import any backend
+// package; each backend package's RegisterBackend registers a Factory, closed
+// over that backend's own config type, under the backend's name.
+type Factory func() (Backend, error)
+
+var factories = map[string]Factory{}
+
+// Register makes factory available under name for New to look up. Call this
+// once at startup for every backend the deployment might select.
+func Register(name string, factory Factory) {
+	factories[name] = factory
+}
+
+// New constructs the Backend registered under name, e.g. the value of
+// etc.Scanner.Backend.
+func New(name string) (Backend, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, xerrors.Errorf("unknown scanner backend: %s", name)
+	}
+	return factory()
+}
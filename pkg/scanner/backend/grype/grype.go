@@ -0,0 +1,122 @@
+package grype
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/etc"
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/scanner"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// backend drives the grype CLI and maps its JSON schema onto scanner.Report,
+// so a deployment can run grype instead of (or alongside) trivy behind the
+// same scanner.Backend contract.
+type backend struct {
+	config etc.Grype
+}
+
+// New constructs a scanner.Backend backed by the grype CLI.
+func New(config etc.Grype) (scanner.Backend, error) {
+	return &backend{config: config}, nil
+}
+
+// RegisterBackend registers this package's backend under the name "grype",
+// so scanner.New("grype") (and etc.Scanner.Backend = "grype") can construct
+// it. Call this once at startup, before any etc.Scanner.Backend-driven scan
+// is served.
+func RegisterBackend(config etc.Grype) {
+	scanner.Register("grype", func() (scanner.Backend, error) {
+		return New(config)
+	})
+}
+
+func (b *backend) Run(imageRef string, auth scanner.Auth, opts scanner.Options) (report scanner.Report, err error) {
+	log.WithField("image_ref", imageRef).Debug("Started scanning with grype")
+
+	executable, err := exec.LookPath("grype")
+	if err != nil {
+		return report, err
+	}
+
+	args := []string{imageRef, "-o", "json"}
+	if b.config.IgnoreUnfixed || opts.IgnoreUnfixed {
+		args = append(args, "--only-fixed")
+	}
+
+	log.WithFields(log.Fields{"cmd": executable, "args": args}).Trace("Exec command with args")
+
+	cmd := exec.Command(executable, args...)
+	cmd.Env = os.Environ()
+	if auth.Username != "" && auth.Password != "" {
+		cmd.Env = append(cmd.Env,
+			fmt.Sprintf("GRYPE_REGISTRY_AUTH_USERNAME=%s", auth.Username),
+			fmt.Sprintf("GRYPE_REGISTRY_AUTH_PASSWORD=%s", auth.Password))
+	}
+
+	stderrBuffer := bytes.Buffer{}
+	cmd.Stderr = &stderrBuffer
+
+	stdout, err := cmd.Output()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"image_ref": imageRef,
+			"std_err":   stderrBuffer.String(),
+		}).Error("Running grype failed")
+		return report, xerrors.Errorf("running grype: %v: %v", err, stderrBuffer.String())
+	}
+
+	return parseReport(imageRef, stdout)
+}
+
+// grypeReport mirrors the subset of `grype -o json`'s schema this package
+// maps onto scanner.Report.
+type grypeReport struct {
+	Matches []struct {
+		Vulnerability struct {
+			ID       string `json:"id"`
+			Severity string `json:"severity"`
+			Fix      struct {
+				Versions []string `json:"versions"`
+			} `json:"fix"`
+		} `json:"vulnerability"`
+		Artifact struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"artifact"`
+	} `json:"matches"`
+}
+
+func parseReport(imageRef string, data []byte) (report scanner.Report, err error) {
+	var g grypeReport
+	if err := json.Unmarshal(data, &g); err != nil {
+		return report, xerrors.Errorf("decoding grype report: %w", err)
+	}
+
+	report.Target = imageRef
+	report.Vulnerabilities = make([]scanner.Vulnerability, 0, len(g.Matches))
+	for _, match := range g.Matches {
+		var fixedVersion string
+		if len(match.Vulnerability.Fix.Versions) > 0 {
+			fixedVersion = match.Vulnerability.Fix.Versions[0]
+		}
+		report.Vulnerabilities = append(report.Vulnerabilities, scanner.Vulnerability{
+			VulnerabilityID:  match.Vulnerability.ID,
+			PkgName:          match.Artifact.Name,
+			InstalledVersion: match.Artifact.Version,
+			FixedVersion:     fixedVersion,
+			Severity:         match.Vulnerability.Severity,
+		})
+	}
+
+	log.WithFields(log.Fields{
+		"image_ref":       imageRef,
+		"vulnerabilities": len(report.Vulnerabilities),
+	}).Debug("Parsed grype report")
+
+	return report, nil
+}
@@ -0,0 +1,55 @@
+package grype
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/etc"
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/scanner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReport(t *testing.T) {
+	data := []byte(`{
+		"matches": [
+			{
+				"vulnerability": {
+					"id": "CVE-2019-1",
+					"severity": "High",
+					"fix": {"versions": ["1.2.4"]}
+				},
+				"artifact": {"name": "musl", "version": "1.2.3"}
+			}
+		]
+	}`)
+
+	report, err := parseReport("alpine:3.10", data)
+	require.NoError(t, err)
+
+	assert.Equal(t, "alpine:3.10", report.Target)
+	require.Len(t, report.Vulnerabilities, 1)
+
+	vuln := report.Vulnerabilities[0]
+	assert.Equal(t, "CVE-2019-1", vuln.VulnerabilityID)
+	assert.Equal(t, "musl", vuln.PkgName)
+	assert.Equal(t, "1.2.3", vuln.InstalledVersion)
+	assert.Equal(t, "1.2.4", vuln.FixedVersion)
+	assert.Equal(t, "High", vuln.Severity)
+}
+
+func TestParseReport_NoFixVersion(t *testing.T) {
+	data := []byte(`{"matches": [{"vulnerability": {"id": "CVE-2019-2", "severity": "Low"}, "artifact": {"name": "libc", "version": "2.0"}}]}`)
+
+	report, err := parseReport("alpine:3.10", data)
+	require.NoError(t, err)
+	require.Len(t, report.Vulnerabilities, 1)
+	assert.Empty(t, report.Vulnerabilities[0].FixedVersion)
+}
+
+func TestRegisterBackend(t *testing.T) {
+	RegisterBackend(etc.Grype{})
+
+	b, err := scanner.New("grype")
+	require.NoError(t, err)
+	assert.NotNil(t, b)
+}
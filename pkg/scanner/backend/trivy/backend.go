@@ -0,0 +1,86 @@
+package trivy
+
+import (
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/etc"
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/scanner"
+)
+
+// backendAdapter makes a Wrapper (or a *ScanExecutor fronting a pool of
+// them) satisfy scanner.Backend.
+type backendAdapter struct {
+	config etc.Trivy
+	runner Wrapper
+}
+
+// NewBackend constructs a scanner.Backend from config. When config.Concurrency
+// is greater than 1, scans are routed through a ScanExecutor so concurrent
+// requests don't exec trivy directly against a single shared vuln DB; a
+// Concurrency of 0 or 1 runs every scan through a single Wrapper, same as
+// before ScanExecutor existed.
+func NewBackend(config etc.Trivy) (scanner.Backend, error) {
+	runner, err := newRunner(config)
+	if err != nil {
+		return nil, err
+	}
+	return &backendAdapter{config: config, runner: runner}, nil
+}
+
+func newRunner(config etc.Trivy) (Wrapper, error) {
+	if config.Concurrency > 1 {
+		return NewScanExecutor(config)
+	}
+	return New(config)
+}
+
+// RegisterBackend registers this package's backend under the name "trivy",
+// so scanner.New("trivy") (and etc.Scanner.Backend = "trivy") can construct
+// it. Call this once at startup, before any etc.Scanner.Backend-driven scan
+// is served.
+func RegisterBackend(config etc.Trivy) {
+	scanner.Register("trivy", func() (scanner.Backend, error) {
+		return NewBackend(config)
+	})
+}
+
+// Run scans imageRef through the backend's runner. If opts overrides any of
+// Severity/VulnType/IgnoreUnfixed from what the backend was constructed
+// with, the scan instead runs through a one-off Wrapper built from the
+// overridden config, bypassing the shared runner (and, if config.Concurrency
+// set one up, its worker pool) for that one call.
+func (b *backendAdapter) Run(imageRef string, auth scanner.Auth, opts scanner.Options) (scanner.Report, error) {
+	runner, err := b.runnerFor(opts)
+	if err != nil {
+		return scanner.Report{}, err
+	}
+	return runner.Run(imageRef, auth)
+}
+
+// RunSBOM implements scanner.SBOMProvider.
+func (b *backendAdapter) RunSBOM(imageRef string, auth scanner.Auth, format string) ([]byte, error) {
+	return b.runner.RunSBOM(imageRef, auth, format)
+}
+
+func (b *backendAdapter) runnerFor(opts scanner.Options) (Wrapper, error) {
+	if !overridesConfig(b.config, opts) {
+		return b.runner, nil
+	}
+
+	overridden := b.config
+	if opts.Severity != "" {
+		overridden.Severity = opts.Severity
+	}
+	if opts.VulnType != "" {
+		overridden.VulnType = opts.VulnType
+	}
+	overridden.IgnoreUnfixed = opts.IgnoreUnfixed
+
+	return New(overridden)
+}
+
+// overridesConfig reports whether opts asks for anything other than what
+// config was constructed with.
+func overridesConfig(config etc.Trivy, opts scanner.Options) bool {
+	return (opts.Severity != "" && opts.Severity != config.Severity) ||
+		(opts.VulnType != "" && opts.VulnType != config.VulnType) ||
+		opts.IgnoreUnfixed != config.IgnoreUnfixed
+}
@@ -0,0 +1,107 @@
+package trivy
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/etc"
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/scanner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterBackend(t *testing.T) {
+	RegisterBackend(etc.Trivy{})
+
+	b, err := scanner.New("trivy")
+	require.NoError(t, err)
+	assert.NotNil(t, b)
+}
+
+func TestNewBackend_RoutesThroughScanExecutorWhenConcurrent(t *testing.T) {
+	execLookPath = fakeLookPath
+	execCommand = fakeExecutorCommand("v1")
+	defer func() {
+		execLookPath = exec.LookPath
+		execCommand = exec.Command
+	}()
+
+	cacheDir, err := ioutil.TempDir("", "backend-cache-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	b, err := NewBackend(etc.Trivy{
+		CacheDir:    cacheDir,
+		ReportsDir:  os.TempDir(),
+		Concurrency: 2,
+	})
+	require.NoError(t, err)
+
+	adapter, ok := b.(*backendAdapter)
+	require.True(t, ok)
+	_, ok = adapter.runner.(*ScanExecutor)
+	assert.True(t, ok, "expected backendAdapter to route through a *ScanExecutor")
+
+	report, err := b.Run("core.harbor.domain/library/mongo:3.10-sles", scanner.Auth{}, scanner.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "t", report.Target)
+}
+
+func TestBackendAdapter_RunSBOM_DelegatesToRunner(t *testing.T) {
+	var capturedArgs []string
+	execLookPath = fakeLookPath
+	execCommand = fakeCommand(&capturedArgs)
+	defer func() {
+		execLookPath = exec.LookPath
+		execCommand = exec.Command
+	}()
+
+	b, err := NewBackend(etc.Trivy{
+		Mode:       etc.ModeStandalone,
+		CacheDir:   "/home/scanner/.cache/trivy",
+		ReportsDir: os.TempDir(),
+	})
+	require.NoError(t, err)
+
+	provider, ok := b.(scanner.SBOMProvider)
+	require.True(t, ok)
+
+	_, _ = provider.RunSBOM("core.harbor.domain/library/mongo:3.10-sles", scanner.Auth{}, "cyclonedx")
+
+	require.Contains(t, capturedArgs, "--format")
+	for i, arg := range capturedArgs {
+		if arg == "--format" {
+			require.Greater(t, len(capturedArgs), i+1)
+			assert.Equal(t, "cyclonedx", capturedArgs[i+1])
+		}
+	}
+}
+
+func TestBackendAdapter_Run_OverridesConfigWithOpts(t *testing.T) {
+	var capturedArgs []string
+	execLookPath = fakeLookPath
+	execCommand = fakeCommand(&capturedArgs)
+	defer func() {
+		execLookPath = exec.LookPath
+		execCommand = exec.Command
+	}()
+
+	b, err := NewBackend(etc.Trivy{
+		Mode:       etc.ModeStandalone,
+		CacheDir:   "/home/scanner/.cache/trivy",
+		ReportsDir: os.TempDir(),
+		Severity:   "HIGH,CRITICAL",
+	})
+	require.NoError(t, err)
+
+	_, _ = b.Run("core.harbor.domain/library/mongo:3.10-sles", scanner.Auth{}, scanner.Options{
+		Severity:      "LOW",
+		IgnoreUnfixed: true,
+	})
+
+	assert.Contains(t, capturedArgs, "LOW")
+	assert.NotContains(t, capturedArgs, "HIGH,CRITICAL")
+	assert.Contains(t, capturedArgs, "--ignore-unfixed")
+}
@@ -0,0 +1,65 @@
+//go:build integration
+
+package trivy
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/etc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureImageRef is scanned by both runtimes below. It's pulled from the
+// network, hence the integration build tag.
+const fixtureImageRef = "alpine:3.10"
+
+// TestWrapper_Contract runs every Wrapper implementation against the same
+// fixture image and config, and requires them to agree on the resulting
+// vulnerability set. Without this, a runtime could silently apply
+// Severity/IgnoreUnfixed (or not) and drift from what the other returns for
+// the same config.
+func TestWrapper_Contract(t *testing.T) {
+	cacheDir := t.TempDir()
+	config := etc.Trivy{
+		CacheDir:     cacheDir,
+		ReportsDir:   t.TempDir(),
+		Severity:     "HIGH,CRITICAL",
+		VulnType:     "os,library",
+		ReportFormat: "json",
+	}
+
+	runtimes := map[string]func() (Wrapper, error){
+		"binary": func() (Wrapper, error) {
+			return NewWrapper(config)
+		},
+		"library": func() (Wrapper, error) {
+			return NewLibraryWrapper(config)
+		},
+	}
+
+	reports := map[string]ScanReport{}
+	for name, newWrapper := range runtimes {
+		w, err := newWrapper()
+		require.NoError(t, err)
+
+		report, err := w.Run(fixtureImageRef, RegistryAuth{})
+		require.NoError(t, err)
+		assert.NotEmpty(t, report.Target)
+
+		reports[name] = report
+	}
+
+	assert.ElementsMatch(t, vulnerabilityIDs(reports["binary"]), vulnerabilityIDs(reports["library"]),
+		"binary and library runtimes must return the same vulnerabilities for identical config")
+}
+
+func vulnerabilityIDs(report ScanReport) []string {
+	ids := make([]string, 0, len(report.Vulnerabilities))
+	for _, v := range report.Vulnerabilities {
+		ids = append(ids, v.VulnerabilityID)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,308 @@
+package trivy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/etc"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// jobKind distinguishes the two things a worker's Wrapper can be asked to
+// do, so both share the same queue/back-pressure/cache-sharding machinery.
+type jobKind int
+
+const (
+	jobKindScan jobKind = iota
+	jobKindSBOM
+)
+
+// scanJob is a unit of work submitted to the ScanExecutor.
+type scanJob struct {
+	kind        jobKind
+	imageRef    string
+	auth        RegistryAuth
+	format      string // only set for jobKindSBOM
+	submittedAt time.Time
+	result      chan scanResult
+}
+
+type scanResult struct {
+	report ScanReport
+	sbom   []byte
+	err    error
+}
+
+// ScanExecutor runs scans through a bounded pool of workers, each holding its
+// own cache subdirectory hardlinked from a canonical vuln DB directory that a
+// background updater goroutine keeps fresh. This avoids many concurrent
+// trivy processes corrupting a single shared BoltDB-backed vuln DB, and
+// gives callers back-pressure instead of unbounded fan-out.
+type ScanExecutor struct {
+	config etc.Trivy
+	jobs   chan *scanJob
+
+	dbDir         string
+	workerDBDirs  []string
+	dbUpdateEvery time.Duration
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+}
+
+// defaultDBUpdateInterval is used when config.DBUpdateInterval isn't set,
+// e.g. etc.Trivy{} constructed directly rather than through the env-parser
+// that supplies envDefault:"1h".
+const defaultDBUpdateInterval = time.Hour
+
+// NewScanExecutor starts config.Concurrency workers, each with its own cache
+// subdirectory under config.CacheDir, and a background goroutine that
+// refreshes the canonical vuln DB every config.DBUpdateInterval.
+func NewScanExecutor(config etc.Trivy) (*ScanExecutor, error) {
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	dbUpdateEvery := config.DBUpdateInterval
+	if dbUpdateEvery <= 0 {
+		dbUpdateEvery = defaultDBUpdateInterval
+	}
+
+	dbDir := filepath.Join(config.CacheDir, "db")
+	if err := os.MkdirAll(dbDir, 0755); err != nil {
+		return nil, xerrors.Errorf("creating canonical db dir: %w", err)
+	}
+
+	e := &ScanExecutor{
+		config:        config,
+		jobs:          make(chan *scanJob, concurrency),
+		dbDir:         dbDir,
+		dbUpdateEvery: dbUpdateEvery,
+		stopCh:        make(chan struct{}),
+	}
+
+	for i := 0; i < concurrency; i++ {
+		workerCacheDir := filepath.Join(config.CacheDir, fmt.Sprintf("worker-%d", i))
+		workerDBDir := filepath.Join(workerCacheDir, "db")
+		if err := seedWorkerCacheDir(dbDir, workerDBDir); err != nil {
+			return nil, xerrors.Errorf("seeding worker %d cache dir: %w", i, err)
+		}
+		e.workerDBDirs = append(e.workerDBDirs, workerDBDir)
+
+		workerConfig := config
+		workerConfig.CacheDir = workerCacheDir
+		w, err := New(workerConfig)
+		if err != nil {
+			return nil, xerrors.Errorf("constructing wrapper for worker %d: %w", i, err)
+		}
+
+		e.wg.Add(1)
+		go e.runWorker(w)
+	}
+
+	e.wg.Add(1)
+	go e.runDBUpdater()
+
+	return e, nil
+}
+
+// Submit enqueues a scan and blocks until a worker has run it, logging queue
+// depth at submission time and scan latency on completion.
+func (e *ScanExecutor) Submit(imageRef string, auth RegistryAuth) (ScanReport, error) {
+	result := e.submit(&scanJob{
+		kind:        jobKindScan,
+		imageRef:    imageRef,
+		auth:        auth,
+		submittedAt: time.Now(),
+		result:      make(chan scanResult, 1),
+	})
+	return result.report, result.err
+}
+
+// SubmitSBOM enqueues an SBOM request and blocks until a worker has run it,
+// the same way Submit does for a vulnerability scan.
+func (e *ScanExecutor) SubmitSBOM(imageRef string, auth RegistryAuth, format string) ([]byte, error) {
+	result := e.submit(&scanJob{
+		kind:        jobKindSBOM,
+		imageRef:    imageRef,
+		auth:        auth,
+		format:      format,
+		submittedAt: time.Now(),
+		result:      make(chan scanResult, 1),
+	})
+	return result.sbom, result.err
+}
+
+// Run scans imageRef through the worker pool. It has the same signature as
+// Wrapper.Run so a ScanExecutor can be used anywhere a Wrapper is, e.g. by
+// backendAdapter.
+func (e *ScanExecutor) Run(imageRef string, auth RegistryAuth) (ScanReport, error) {
+	return e.Submit(imageRef, auth)
+}
+
+// RunSBOM generates an SBOM document through the worker pool. It has the
+// same signature as Wrapper.RunSBOM so a ScanExecutor can be used anywhere a
+// Wrapper is, e.g. by backendAdapter.
+func (e *ScanExecutor) RunSBOM(imageRef string, auth RegistryAuth, format string) ([]byte, error) {
+	return e.SubmitSBOM(imageRef, auth, format)
+}
+
+func (e *ScanExecutor) submit(job *scanJob) scanResult {
+	log.WithFields(log.Fields{
+		"image_ref":   job.imageRef,
+		"queue_depth": len(e.jobs),
+	}).Debug("Enqueuing scan job")
+
+	e.jobs <- job
+	result := <-job.result
+
+	log.WithFields(log.Fields{
+		"image_ref":    job.imageRef,
+		"scan_latency": time.Since(job.submittedAt).String(),
+	}).Debug("Scan job finished")
+
+	return result
+}
+
+// Shutdown stops all workers and the background DB updater. It waits for
+// jobs a worker has already started to finish; jobs still queued may never
+// run, and their Submit calls then do not return.
+func (e *ScanExecutor) Shutdown() {
+	close(e.stopCh)
+	e.wg.Wait()
+}
+
+func (e *ScanExecutor) runWorker(w Wrapper) {
+	defer e.wg.Done()
+	for {
+		select {
+		case job := <-e.jobs:
+			job.result <- e.runJob(w, job)
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+func (e *ScanExecutor) runJob(w Wrapper, job *scanJob) scanResult {
+	if job.kind == jobKindSBOM {
+		sbom, err := w.RunSBOM(job.imageRef, job.auth, job.format)
+		return scanResult{sbom: sbom, err: err}
+	}
+	report, err := w.Run(job.imageRef, job.auth)
+	return scanResult{report: report, err: err}
+}
+
+// runDBUpdater periodically downloads a fresh vuln DB into a staging
+// directory and swaps it in as the canonical DB dir, mirroring the
+// stage-then-rename pattern trivy-db uses when passed an explicit DB dir.
+// The swap is two renames (old dir aside, new dir in), not one atomic step:
+// between them the canonical DB dir does not exist.
+func (e *ScanExecutor) runDBUpdater() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.dbUpdateEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.updateCanonicalDB(); err != nil {
+				log.WithError(err).Error("Updating canonical vuln DB failed")
+			}
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+func (e *ScanExecutor) updateCanonicalDB() error {
+	stagingDir, err := ioutil.TempDir(e.config.CacheDir, "db-staging-*")
+	if err != nil {
+		return xerrors.Errorf("creating staging db dir: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	executable, err := execLookPath("trivy")
+	if err != nil {
+		return err
+	}
+
+	args := []string{"image", "--download-db-only", "--cache-dir", stagingDir}
+	log.WithFields(log.Fields{"cmd": executable, "args": args}).Debug("Downloading vuln DB")
+
+	cmd := execCommand(executable, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return xerrors.Errorf("downloading vuln db: %v: %s", err, string(out))
+	}
+
+	swapDir := e.dbDir + ".new"
+	if err := os.Rename(filepath.Join(stagingDir, "db"), swapDir); err != nil {
+		return xerrors.Errorf("staging new db dir: %w", err)
+	}
+	oldDir := e.dbDir + ".old"
+	_ = os.RemoveAll(oldDir)
+	if err := os.Rename(e.dbDir, oldDir); err != nil {
+		return xerrors.Errorf("moving old db dir aside: %w", err)
+	}
+	if err := os.Rename(swapDir, e.dbDir); err != nil {
+		return xerrors.Errorf("swapping in new db dir: %w", err)
+	}
+	_ = os.RemoveAll(oldDir)
+
+	log.Info("Canonical vuln DB updated")
+
+	for _, workerDBDir := range e.workerDBDirs {
+		if err := seedWorkerCacheDir(e.dbDir, workerDBDir); err != nil {
+			log.WithError(err).WithField("worker_db_dir", workerDBDir).Error("Re-seeding worker cache dir after DB update failed")
+		}
+	}
+
+	return nil
+}
+
+// seedWorkerCacheDir populates workerDBDir with hardlinks (falling back to
+// plain copies across filesystems) to every file under canonicalDBDir, so
+// each worker gets its own writable vuln DB handle without duplicating the
+// full DB on disk.
+func seedWorkerCacheDir(canonicalDBDir, workerDBDir string) error {
+	if err := os.MkdirAll(workerDBDir, 0755); err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(canonicalDBDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		src := filepath.Join(canonicalDBDir, entry.Name())
+		dst := filepath.Join(workerDBDir, entry.Name())
+		_ = os.Remove(dst)
+		if err := os.Link(src, dst); err != nil {
+			if err := copyFile(src, dst); err != nil {
+				return xerrors.Errorf("seeding %s: %w", dst, err)
+			}
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, data, 0644)
+}
@@ -0,0 +1,149 @@
+package trivy
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/etc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExecutorCommand fakes both a scan invocation (writes a minimal valid
+// report to the --output path) and a `trivy image --download-db-only`
+// invocation (writes dbContent under <--cache-dir>/db/trivy.db), so both
+// worker scans and updateCanonicalDB can be exercised without a real trivy
+// binary.
+func fakeExecutorCommand(dbContent string) func(name string, arg ...string) *exec.Cmd {
+	return func(name string, arg ...string) *exec.Cmd {
+		for i, a := range arg {
+			switch a {
+			case "--output":
+				if i+1 < len(arg) {
+					_ = ioutil.WriteFile(arg[i+1], []byte(`[{"Target":"t","Vulnerabilities":[]}]`), 0644)
+				}
+			case "--cache-dir":
+				if i+1 < len(arg) && contains(arg, "--download-db-only") {
+					dbDir := filepath.Join(arg[i+1], "db")
+					_ = os.MkdirAll(dbDir, 0755)
+					_ = ioutil.WriteFile(filepath.Join(dbDir, "trivy.db"), []byte(dbContent), 0644)
+				}
+			}
+		}
+		cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+		cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
+		return cmd
+	}
+}
+
+func TestSeedWorkerCacheDir_HardlinksCanonicalFiles(t *testing.T) {
+	canonicalDir, err := ioutil.TempDir("", "canonical-db-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(canonicalDir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(canonicalDir, "trivy.db"), []byte("fake-db"), 0644))
+
+	workerDir, err := ioutil.TempDir("", "worker-db-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(workerDir)
+
+	require.NoError(t, seedWorkerCacheDir(canonicalDir, workerDir))
+
+	data, err := ioutil.ReadFile(filepath.Join(workerDir, "trivy.db"))
+	require.NoError(t, err)
+	assert.Equal(t, "fake-db", string(data))
+}
+
+func TestSeedWorkerCacheDir_MissingCanonicalDirIsNotAnError(t *testing.T) {
+	workerDir, err := ioutil.TempDir("", "worker-db-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(workerDir)
+
+	err = seedWorkerCacheDir(filepath.Join(workerDir, "does-not-exist"), filepath.Join(workerDir, "db"))
+	assert.NoError(t, err)
+}
+
+func TestNewScanExecutor_SubmitRunsJobThroughWorker(t *testing.T) {
+	execLookPath = fakeLookPath
+	execCommand = fakeExecutorCommand("v1")
+	defer func() {
+		execLookPath = exec.LookPath
+		execCommand = exec.Command
+	}()
+
+	cacheDir, err := ioutil.TempDir("", "executor-cache-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	e, err := NewScanExecutor(etc.Trivy{
+		CacheDir:     cacheDir,
+		ReportsDir:   os.TempDir(),
+		Concurrency:  2,
+		Severity:     "HIGH,CRITICAL",
+		VulnType:     "os,library",
+		ReportFormat: "json",
+	})
+	require.NoError(t, err)
+	defer e.Shutdown()
+
+	report, err := e.Submit("core.harbor.domain/library/mongo:3.10-sles", RegistryAuth{})
+	require.NoError(t, err)
+	assert.Equal(t, "t", report.Target)
+}
+
+func TestScanExecutor_UpdateCanonicalDB_ReseedsWorkerDirs(t *testing.T) {
+	execLookPath = fakeLookPath
+	execCommand = fakeExecutorCommand("v1")
+	defer func() {
+		execLookPath = exec.LookPath
+		execCommand = exec.Command
+	}()
+
+	cacheDir, err := ioutil.TempDir("", "executor-cache-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	e, err := NewScanExecutor(etc.Trivy{
+		CacheDir:         cacheDir,
+		ReportsDir:       os.TempDir(),
+		Concurrency:      2,
+		DBUpdateInterval: time.Hour,
+	})
+	require.NoError(t, err)
+	defer e.Shutdown()
+
+	require.Len(t, e.workerDBDirs, 2)
+
+	// Next update downloads a new DB snapshot; workers must pick it up.
+	execCommand = fakeExecutorCommand("v2")
+	require.NoError(t, e.updateCanonicalDB())
+
+	for _, workerDBDir := range e.workerDBDirs {
+		data, err := ioutil.ReadFile(filepath.Join(workerDBDir, "trivy.db"))
+		require.NoError(t, err)
+		assert.Equal(t, "v2", string(data))
+	}
+}
+
+func TestNewScanExecutor_DefaultsInvalidDBUpdateIntervalInsteadOfPanicking(t *testing.T) {
+	execLookPath = fakeLookPath
+	execCommand = fakeExecutorCommand("v1")
+	defer func() {
+		execLookPath = exec.LookPath
+		execCommand = exec.Command
+	}()
+
+	cacheDir, err := ioutil.TempDir("", "executor-cache-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	e, err := NewScanExecutor(etc.Trivy{CacheDir: cacheDir, ReportsDir: os.TempDir()})
+	require.NoError(t, err)
+	defer e.Shutdown()
+
+	assert.Equal(t, defaultDBUpdateInterval, e.dbUpdateEvery)
+}
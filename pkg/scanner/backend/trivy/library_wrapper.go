@@ -0,0 +1,138 @@
+package trivy
+
+import (
+	"context"
+	"strings"
+
+	dbTypes "github.com/aquasecurity/trivy-db/pkg/types"
+
+	"github.com/aquasecurity/fanal/analyzer"
+	"github.com/aquasecurity/fanal/applier"
+	"github.com/aquasecurity/fanal/artifact"
+	"github.com/aquasecurity/fanal/artifact/image"
+	"github.com/aquasecurity/fanal/cache"
+	fanalTypes "github.com/aquasecurity/fanal/types"
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/etc"
+	resultpkg "github.com/aquasecurity/trivy/pkg/result"
+	trivyScanner "github.com/aquasecurity/trivy/pkg/scanner"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// libraryWrapper drives Trivy in-process via fanal/scanner instead of
+// exec-ing the trivy binary. It avoids fork/exec overhead per scan, skips
+// the temp-file JSON round-trip that parseScanReports does for the binary
+// runtime, and lets scan progress flow into logrus as structured fields
+// instead of parsed stdout/stderr.
+type libraryWrapper struct {
+	config etc.Trivy
+	cache  cache.Cache
+}
+
+// NewLibraryWrapper constructs a Wrapper that scans using Trivy as a Go
+// library rather than the trivy CLI.
+func NewLibraryWrapper(config etc.Trivy) (Wrapper, error) {
+	fsCache, err := cache.NewFSCache(config.CacheDir)
+	if err != nil {
+		return nil, xerrors.Errorf("initializing fanal fs cache: %w", err)
+	}
+	return &libraryWrapper{
+		config: config,
+		cache:  fsCache,
+	}, nil
+}
+
+func (w *libraryWrapper) Run(imageRef string, auth RegistryAuth) (report ScanReport, err error) {
+	log.WithField("image_ref", imageRef).Debug("Started scanning (library runtime)")
+
+	ctx := context.Background()
+
+	tls, err := resolveRegistryTLS(w.config, imageRef)
+	if err != nil {
+		return report, err
+	}
+	defer tls.cleanup()
+
+	artifactOpt := artifact.Option{
+		SkipFiles:         w.config.SkipFiles,
+		SkipDirs:          w.config.SkipDirs,
+		DisabledAnalyzers: disabledAnalyzerTypes(w.config.DisabledAnalyzers),
+	}
+	dockerOpt := fanalTypes.DockerOption{
+		UserName:              auth.Username,
+		Password:              auth.Password,
+		InsecureSkipTLSVerify: tls.insecure,
+		NonSSL:                tls.nonSSL,
+	}
+	if tls.caFile != "" {
+		dockerOpt.SSLCertPath = tls.caFile
+	}
+
+	art, cleanup, err := image.NewArtifact(imageRef, w.cache, artifactOpt, dockerOpt)
+	if err != nil {
+		return report, xerrors.Errorf("initializing image artifact %s: %w", imageRef, err)
+	}
+	defer cleanup()
+
+	scanner := trivyScanner.NewScanner(applier.NewApplier(w.cache), art)
+
+	results, _, err := scanner.ScanArtifact(ctx, fanalTypes.ScanOptions{
+		VulnType: strings.Split(w.config.VulnType, ","),
+	})
+	if err != nil {
+		return report, xerrors.Errorf("scanning image %s: %w", imageRef, err)
+	}
+
+	severities := parseSeverities(w.config.Severity)
+
+	report.Vulnerabilities = []Vulnerability{}
+	for _, result := range results {
+		log.WithField("target", result.Target).Trace("Parsing vulnerabilities")
+		if report.Target == "" {
+			report.Target = result.Target
+		}
+		// Mirror what the binary runtime gets for free from trivy's own
+		// --severity/--ignore-unfixed flags: the library scan returns every
+		// detected vulnerability, so we apply the same post-scan filter
+		// trivy's CLI applies before rendering its report.
+		filtered := resultpkg.Filter(result.Vulnerabilities, severities, w.config.IgnoreUnfixed, false)
+		report.Vulnerabilities = append(report.Vulnerabilities, filtered...)
+	}
+
+	return report, nil
+}
+
+func (w *libraryWrapper) RunSBOM(imageRef string, auth RegistryAuth, format string) ([]byte, error) {
+	return nil, xerrors.New("SBOM output is not yet supported by the library runtime")
+}
+
+// parseSeverities converts config.Severity ("HIGH,CRITICAL") into the
+// dbTypes.Severity values resultpkg.Filter expects, skipping anything it
+// doesn't recognize rather than failing the scan over a typo.
+func parseSeverities(severity string) []dbTypes.Severity {
+	var severities []dbTypes.Severity
+	for _, s := range strings.Split(severity, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		sev, err := dbTypes.NewSeverity(s)
+		if err != nil {
+			log.WithError(err).WithField("severity", s).Warn("Ignoring unrecognized severity")
+			continue
+		}
+		severities = append(severities, sev)
+	}
+	return severities
+}
+
+// disabledAnalyzerTypes converts the configured analyzer names into fanal's
+// analyzer.Type, the same knob --disabled-analyzers maps to for the binary
+// runtime.
+func disabledAnalyzerTypes(names []string) []analyzer.Type {
+	types := make([]analyzer.Type, 0, len(names))
+	for _, name := range names {
+		types = append(types, analyzer.Type(name))
+	}
+	return types
+}
@@ -0,0 +1,22 @@
+package trivy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSeverities(t *testing.T) {
+	severities := parseSeverities("HIGH,CRITICAL")
+	assert.Len(t, severities, 2)
+}
+
+func TestParseSeverities_IgnoresUnrecognizedEntries(t *testing.T) {
+	severities := parseSeverities("HIGH,NOT-A-SEVERITY,")
+	assert.Len(t, severities, 1)
+}
+
+func TestDisabledAnalyzerTypes(t *testing.T) {
+	types := disabledAnalyzerTypes([]string{"secret", "jar"})
+	assert.Len(t, types, 2)
+}
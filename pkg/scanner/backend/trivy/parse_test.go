@@ -0,0 +1,30 @@
+package trivy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalScanReports_LegacyArray(t *testing.T) {
+	data := []byte(`[{"Target":"alpine:3.10 (alpine 3.10.2)","Vulnerabilities":[{"VulnerabilityID":"CVE-2019-1","PkgName":"musl"}]}]`)
+
+	reports, err := unmarshalScanReports(data)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "alpine:3.10 (alpine 3.10.2)", reports[0].Target)
+	require.Len(t, reports[0].Vulnerabilities, 1)
+	assert.Equal(t, "CVE-2019-1", reports[0].Vulnerabilities[0].VulnerabilityID)
+}
+
+func TestUnmarshalScanReports_ReportEnvelope(t *testing.T) {
+	data := []byte(`{"SchemaVersion":2,"ArtifactName":"alpine:3.10","Results":[{"Target":"alpine:3.10 (alpine 3.10.2)","Vulnerabilities":[{"VulnerabilityID":"CVE-2019-1","PkgName":"musl"}]}]}`)
+
+	reports, err := unmarshalScanReports(data)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "alpine:3.10 (alpine 3.10.2)", reports[0].Target)
+	require.Len(t, reports[0].Vulnerabilities, 1)
+	assert.Equal(t, "CVE-2019-1", reports[0].Vulnerabilities[0].VulnerabilityID)
+}
@@ -0,0 +1,80 @@
+package trivy
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/etc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapper_RegistryEnv_InsecureMatchByHost(t *testing.T) {
+	w := &wrapper{config: etc.Trivy{
+		InsecureRegistries: []string{"insecure.example.com"},
+		NonSSLRegistries:   []string{"plain.example.com"},
+	}}
+
+	env, cleanup, err := w.registryEnv("insecure.example.com/library/mongo:3.10")
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Contains(t, env, "TRIVY_INSECURE=true")
+	assert.NotContains(t, env, "TRIVY_NON_SSL=true")
+}
+
+func TestWrapper_RegistryEnv_NonSSLMatchByHost(t *testing.T) {
+	w := &wrapper{config: etc.Trivy{
+		NonSSLRegistries: []string{"plain.example.com"},
+	}}
+
+	env, cleanup, err := w.registryEnv("plain.example.com/library/mongo:3.10")
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Contains(t, env, "TRIVY_NON_SSL=true")
+}
+
+func TestWrapper_RegistryEnv_NoMatchLeavesTLSEnabled(t *testing.T) {
+	w := &wrapper{config: etc.Trivy{
+		InsecureRegistries: []string{"insecure.example.com"},
+	}}
+
+	env, cleanup, err := w.registryEnv("secure.example.com/library/mongo:3.10")
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Empty(t, env)
+}
+
+func TestWrapper_RegistryEnv_CustomCAFile(t *testing.T) {
+	w := &wrapper{config: etc.Trivy{CustomCAFile: "/etc/ssl/certs/custom-ca.pem"}}
+
+	env, cleanup, err := w.registryEnv("core.harbor.domain/library/mongo:3.10")
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Contains(t, env, "TRIVY_CA_CERT_FILE=/etc/ssl/certs/custom-ca.pem")
+	assert.Contains(t, env, "SSL_CERT_FILE=/etc/ssl/certs/custom-ca.pem")
+}
+
+func TestWrapper_RegistryEnv_InlineCustomCAWrittenToTmpFile(t *testing.T) {
+	w := &wrapper{config: etc.Trivy{CustomCA: "-----BEGIN CERTIFICATE-----\nfake\n-----END CERTIFICATE-----"}}
+
+	env, cleanup, err := w.registryEnv("core.harbor.domain/library/mongo:3.10")
+	require.NoError(t, err)
+	require.NotEmpty(t, env)
+
+	var caFile string
+	for _, e := range env {
+		if len(e) > len("TRIVY_CA_CERT_FILE=") && e[:len("TRIVY_CA_CERT_FILE=")] == "TRIVY_CA_CERT_FILE=" {
+			caFile = e[len("TRIVY_CA_CERT_FILE="):]
+		}
+	}
+	require.NotEmpty(t, caFile)
+
+	content, err := ioutil.ReadFile(caFile)
+	require.NoError(t, err)
+	assert.Equal(t, w.config.CustomCA, string(content))
+
+	cleanup()
+	_, err = os.Stat(caFile)
+	assert.True(t, os.IsNotExist(err))
+}
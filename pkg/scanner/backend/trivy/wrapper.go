@@ -0,0 +1,359 @@
+package trivy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/etc"
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/scanner"
+	"github.com/google/go-containerregistry/pkg/name"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// RegistryAuth, ScanReport and Vulnerability alias the canonical types
+// shared by every scanner.Backend, so this package's existing names keep
+// working unchanged.
+type (
+	RegistryAuth  = scanner.Auth
+	ScanReport    = scanner.Report
+	Vulnerability = scanner.Vulnerability
+)
+
+type Wrapper interface {
+	Run(imageRef string, auth RegistryAuth) (ScanReport, error)
+	// RunSBOM scans imageRef and returns the raw bytes of the report in the
+	// given SBOM format (one of etc.IsSBOMFormat), for the HTTP layer to
+	// serve alongside the Harbor-native vulnerability report from Run.
+	RunSBOM(imageRef string, auth RegistryAuth, format string) ([]byte, error)
+}
+
+type wrapper struct {
+	config etc.Trivy
+}
+
+// execLookPath and execCommand are indirections over the os/exec package
+// functions of the same name, so tests can substitute a fake trivy binary.
+var (
+	execLookPath = exec.LookPath
+	execCommand  = exec.Command
+)
+
+// New constructs a Wrapper for config.Runtime: RuntimeBinary (the default)
+// execs the trivy CLI, RuntimeLibrary drives Trivy in-process.
+func New(config etc.Trivy) (Wrapper, error) {
+	if config.Runtime == etc.RuntimeLibrary {
+		return NewLibraryWrapper(config)
+	}
+	return NewWrapper(config)
+}
+
+// NewWrapper constructs a binary-based Wrapper from the given config. It
+// returns an error if the config is invalid for the selected Mode, e.g.
+// ServerURL is required when Mode is etc.ModeClientServer.
+func NewWrapper(config etc.Trivy) (Wrapper, error) {
+	if config.Mode == etc.ModeClientServer && config.ServerURL == "" {
+		return nil, xerrors.New("trivy server URL must be set when mode is ClientServer")
+	}
+	return &wrapper{
+		config: config,
+	}, nil
+}
+
+func (w *wrapper) Run(imageRef string, auth RegistryAuth) (report ScanReport, err error) {
+	// Always scan for the native vulnerability JSON here, independent of
+	// ReportFormat: parseScanReports only understands that schema. SBOM
+	// formats are requested through RunSBOM's format argument instead.
+	reportBytes, err := w.runTrivy(imageRef, auth, "json", "scan_report_*.json")
+	if err != nil {
+		return report, err
+	}
+	return w.parseScanReports(bytes.NewReader(reportBytes))
+}
+
+func (w *wrapper) RunSBOM(imageRef string, auth RegistryAuth, format string) ([]byte, error) {
+	if !etc.IsSBOMFormat(format) {
+		return nil, xerrors.Errorf("unsupported SBOM format: %s", format)
+	}
+	return w.runTrivy(imageRef, auth, format, "scan_report_*."+format)
+}
+
+// runTrivy execs trivy against imageRef with the given report format,
+// writing its output to a tmp file (named after pattern, in ReportsDir) and
+// returning that file's contents.
+func (w *wrapper) runTrivy(imageRef string, auth RegistryAuth, format, pattern string) ([]byte, error) {
+	log.WithField("image_ref", imageRef).Debug("Started scanning")
+
+	executable, err := execLookPath("trivy")
+	if err != nil {
+		return nil, err
+	}
+
+	reportFile, err := ioutil.TempFile(w.config.ReportsDir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	log.WithField("path", reportFile.Name()).Debug("Saving scan report to tmp file")
+	defer func() {
+		log.WithField("path", reportFile.Name()).Debug("Removing scan report tmp file")
+		err := os.Remove(reportFile.Name())
+		if err != nil {
+			log.WithError(err).Warn("Error while removing scan report file")
+		}
+	}()
+
+	args := w.buildArgs(imageRef, reportFile.Name(), format)
+
+	log.WithFields(log.Fields{"cmd": executable, "args": args}).Trace("Exec command with args")
+
+	cmd := execCommand(executable, args...)
+
+	registryEnv, cleanup, err := w.registryEnv(imageRef)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	cmd.Env = append(os.Environ(), registryEnv...)
+	if auth.Username != "" && auth.Password != "" {
+		cmd.Env = append(cmd.Env,
+			fmt.Sprintf("TRIVY_USERNAME=%s", auth.Username),
+			fmt.Sprintf("TRIVY_PASSWORD=%s", auth.Password))
+	}
+
+	stderrBuffer := bytes.Buffer{}
+
+	cmd.Stderr = &stderrBuffer
+
+	stdout, err := cmd.Output()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"image_ref": imageRef,
+			"exit_code": cmd.ProcessState.ExitCode(),
+			"std_err":   stderrBuffer.String(),
+			"std_out":   string(stdout),
+		}).Error("Running trivy failed")
+		return nil, xerrors.Errorf("running trivy: %v: %v", err, stderrBuffer.String())
+	}
+
+	log.WithFields(log.Fields{
+		"image_ref": imageRef,
+		"exit_code": cmd.ProcessState.ExitCode(),
+		"std_err":   stderrBuffer.String(),
+		"std_out":   string(stdout),
+	}).Debug("Running trivy finished")
+
+	return ioutil.ReadFile(reportFile.Name())
+}
+
+// buildArgs assembles the trivy CLI arguments for the configured Mode:
+// Standalone scans against a local (or lazily downloaded) vuln DB, while
+// ClientServer delegates the scan to a shared `trivy server` instance via
+// `trivy client`.
+func (w *wrapper) buildArgs(imageRef, reportPath, format string) []string {
+	var args []string
+
+	if w.config.Mode == etc.ModeClientServer {
+		args = []string{
+			"client",
+			"--remote", w.config.ServerURL,
+		}
+		if w.config.ServerToken != "" {
+			args = append(args, "--token", w.config.ServerToken)
+			if w.config.ServerTokenHeader != "" {
+				args = append(args, "--token-header", w.config.ServerTokenHeader)
+			}
+		}
+		for _, header := range w.config.ServerCustomHeaders {
+			args = append(args, "--custom-headers", header)
+		}
+	} else {
+		args = []string{
+			"--cache-dir", w.config.CacheDir,
+		}
+	}
+
+	args = append(args,
+		"--no-progress",
+		"--severity", w.config.Severity,
+		"--vuln-type", w.config.VulnType,
+		"--format", format,
+		"--output", reportPath,
+	)
+
+	if len(w.config.SkipDirs) > 0 {
+		args = append(args, "--skip-dirs", joinComma(w.config.SkipDirs))
+	}
+	if len(w.config.SkipFiles) > 0 {
+		args = append(args, "--skip-files", joinComma(w.config.SkipFiles))
+	}
+	if len(w.config.DisabledAnalyzers) > 0 {
+		args = append(args, "--disabled-analyzers", joinComma(w.config.DisabledAnalyzers))
+	}
+
+	args = append(args, imageRef)
+
+	if w.config.IgnoreUnfixed {
+		args = append([]string{"--ignore-unfixed"}, args...)
+	}
+
+	if w.config.DebugMode {
+		args = append([]string{"--debug"}, args...)
+	}
+
+	return args
+}
+
+func joinComma(values []string) string {
+	joined := ""
+	for i, v := range values {
+		if i > 0 {
+			joined += ","
+		}
+		joined += v
+	}
+	return joined
+}
+
+// registryEnv inspects imageRef's registry host against the configured
+// InsecureRegistries/NonSSLRegistries and returns the TRIVY_INSECURE,
+// TRIVY_NON_SSL and CA-related environment variables for that registry only,
+// so a single deployment can scan a mix of TLS, mTLS and plain-HTTP
+// registries without disabling TLS verification globally. The returned
+// cleanup func removes any temp file written for an inline CA bundle and
+// must always be called.
+func (w *wrapper) registryEnv(imageRef string) (env []string, cleanup func(), err error) {
+	tls, err := resolveRegistryTLS(w.config, imageRef)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	if tls.insecure {
+		env = append(env, "TRIVY_INSECURE=true")
+	}
+	if tls.nonSSL {
+		env = append(env, "TRIVY_NON_SSL=true")
+	}
+	if tls.caFile != "" {
+		env = append(env,
+			fmt.Sprintf("TRIVY_CA_CERT_FILE=%s", tls.caFile),
+			fmt.Sprintf("SSL_CERT_FILE=%s", tls.caFile),
+		)
+	}
+
+	return env, tls.cleanup, nil
+}
+
+// registryTLS is the per-registry TLS/CA decision resolveRegistryTLS makes
+// for a given image ref, shared by the binary and library runtimes so both
+// honor InsecureRegistries/NonSSLRegistries/CustomCA(File) identically.
+type registryTLS struct {
+	insecure bool
+	nonSSL   bool
+	caFile   string
+	cleanup  func()
+}
+
+// resolveRegistryTLS inspects imageRef's registry host against
+// config.InsecureRegistries/NonSSLRegistries and resolves the custom CA
+// bundle, writing an inline CustomCA to a temp file if needed. The returned
+// cleanup func removes that temp file (a no-op otherwise) and must always be
+// called.
+func resolveRegistryTLS(config etc.Trivy, imageRef string) (registryTLS, error) {
+	tls := registryTLS{cleanup: func() {}}
+
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return tls, xerrors.Errorf("parsing image ref %s: %w", imageRef, err)
+	}
+	host := ref.Context().RegistryStr()
+
+	tls.insecure = contains(config.InsecureRegistries, host)
+	tls.nonSSL = contains(config.NonSSLRegistries, host)
+
+	caFile := config.CustomCAFile
+	if caFile == "" && config.CustomCA != "" {
+		caTmpFile, err := ioutil.TempFile("", "trivy_custom_ca_*.pem")
+		if err != nil {
+			return tls, xerrors.Errorf("writing custom CA to tmp file: %w", err)
+		}
+		if _, err := caTmpFile.WriteString(config.CustomCA); err != nil {
+			_ = caTmpFile.Close()
+			_ = os.Remove(caTmpFile.Name())
+			return tls, xerrors.Errorf("writing custom CA to tmp file: %w", err)
+		}
+		_ = caTmpFile.Close()
+		caFile = caTmpFile.Name()
+		tls.cleanup = func() {
+			if err := os.Remove(caFile); err != nil {
+				log.WithError(err).Warn("Error while removing custom CA tmp file")
+			}
+		}
+	}
+	tls.caFile = caFile
+
+	return tls, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// reportEnvelope is the top-level object newer Trivy versions (schema v2+)
+// wrap scan results in, as opposed to the legacy bare JSON array of
+// ScanReport.
+type reportEnvelope struct {
+	Results []ScanReport `json:"Results"`
+}
+
+func (w *wrapper) parseScanReports(reportFile io.Reader) (report ScanReport, err error) {
+	data, err := ioutil.ReadAll(reportFile)
+	if err != nil {
+		return report, xerrors.Errorf("reading scan report: %w", err)
+	}
+
+	scanReports, err := unmarshalScanReports(data)
+	if err != nil {
+		return report, err
+	}
+
+	if len(scanReports) == 0 {
+		return report, xerrors.New("expected at least one report")
+	}
+
+	// Collect all vulnerabilities to single scanReport to allow showing those in Harbor
+	report.Target = scanReports[0].Target
+	report.Vulnerabilities = []Vulnerability{}
+	for _, scanReport := range scanReports {
+		log.WithField("target", scanReport.Target).Trace("Parsing vulnerabilities")
+		report.Vulnerabilities = append(report.Vulnerabilities, scanReport.Vulnerabilities...)
+	}
+
+	return
+}
+
+// unmarshalScanReports decodes data as either the legacy bare []ScanReport
+// array or the reportEnvelope{Results: [...]} schema introduced by newer
+// Trivy versions, so upgrading the Trivy binary doesn't break decoding.
+func unmarshalScanReports(data []byte) ([]ScanReport, error) {
+	var scanReports []ScanReport
+	if err := json.Unmarshal(data, &scanReports); err == nil {
+		return scanReports, nil
+	}
+
+	var envelope reportEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, xerrors.Errorf("decoding scan report: %w", err)
+	}
+	return envelope.Results, nil
+}
@@ -0,0 +1,138 @@
+package trivy
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/aquasecurity/harbor-scanner-trivy/pkg/etc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLookPath pretends the requested binary exists at a stable path without
+// touching the real PATH.
+func fakeLookPath(file string) (string, error) {
+	return "/usr/local/bin/" + file, nil
+}
+
+// fakeCommand records the args it was called with and returns a Cmd that
+// exits successfully without exec-ing anything real.
+func fakeCommand(capturedArgs *[]string) func(name string, arg ...string) *exec.Cmd {
+	return func(name string, arg ...string) *exec.Cmd {
+		*capturedArgs = arg
+		cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+		cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
+		return cmd
+	}
+}
+
+// TestHelperProcess isn't a real test; it's the fake trivy process spawned by
+// fakeCommand. See https://pkg.go.dev/os/exec#Command for the pattern.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	os.Exit(0)
+}
+
+func TestWrapper_BuildArgs_Standalone(t *testing.T) {
+	var capturedArgs []string
+	execLookPath = fakeLookPath
+	execCommand = fakeCommand(&capturedArgs)
+	defer func() {
+		execLookPath = exec.LookPath
+		execCommand = exec.Command
+	}()
+
+	config := etc.Trivy{
+		Mode:         etc.ModeStandalone,
+		CacheDir:     "/home/scanner/.cache/trivy",
+		ReportsDir:   os.TempDir(),
+		Severity:     "HIGH,CRITICAL",
+		VulnType:     "os,library",
+		ReportFormat: "json",
+	}
+
+	w, err := NewWrapper(config)
+	require.NoError(t, err)
+
+	_, _ = w.Run("core.harbor.domain/library/mongo:3.10-sles", RegistryAuth{})
+
+	assert.Contains(t, capturedArgs, "--cache-dir")
+	assert.Contains(t, capturedArgs, "/home/scanner/.cache/trivy")
+	assert.NotContains(t, capturedArgs, "client")
+}
+
+func TestWrapper_BuildArgs_ClientServer(t *testing.T) {
+	var capturedArgs []string
+	execLookPath = fakeLookPath
+	execCommand = fakeCommand(&capturedArgs)
+	defer func() {
+		execLookPath = exec.LookPath
+		execCommand = exec.Command
+	}()
+
+	config := etc.Trivy{
+		Mode:              etc.ModeClientServer,
+		ServerURL:         "http://trivy.trivy:4954",
+		ServerToken:       "s3cr3t",
+		ServerTokenHeader: "Trivy-Token",
+		ReportsDir:        os.TempDir(),
+		Severity:          "HIGH,CRITICAL",
+		VulnType:          "os,library",
+		ReportFormat:      "json",
+	}
+
+	w, err := NewWrapper(config)
+	require.NoError(t, err)
+
+	_, _ = w.Run("core.harbor.domain/library/mongo:3.10-sles", RegistryAuth{})
+
+	require.NotEmpty(t, capturedArgs)
+	assert.Equal(t, "client", capturedArgs[0])
+	assert.Contains(t, capturedArgs, "--remote")
+	assert.Contains(t, capturedArgs, "http://trivy.trivy:4954")
+	assert.Contains(t, capturedArgs, "--token")
+	assert.Contains(t, capturedArgs, "s3cr3t")
+	assert.NotContains(t, capturedArgs, "--cache-dir")
+}
+
+func TestWrapper_Run_AlwaysUsesVulnerabilityJSONFormat(t *testing.T) {
+	var capturedArgs []string
+	execLookPath = fakeLookPath
+	execCommand = fakeCommand(&capturedArgs)
+	defer func() {
+		execLookPath = exec.LookPath
+		execCommand = exec.Command
+	}()
+
+	// ReportFormat is configured for SBOM output; Run must still request the
+	// native vulnerability JSON, or parseScanReports fails on every scan.
+	config := etc.Trivy{
+		Mode:         etc.ModeStandalone,
+		CacheDir:     "/home/scanner/.cache/trivy",
+		ReportsDir:   os.TempDir(),
+		Severity:     "HIGH,CRITICAL",
+		VulnType:     "os,library",
+		ReportFormat: "cyclonedx",
+	}
+
+	w, err := NewWrapper(config)
+	require.NoError(t, err)
+
+	_, _ = w.Run("core.harbor.domain/library/mongo:3.10-sles", RegistryAuth{})
+
+	require.Contains(t, capturedArgs, "--format")
+	for i, arg := range capturedArgs {
+		if arg == "--format" {
+			require.Greater(t, len(capturedArgs), i+1)
+			assert.Equal(t, "json", capturedArgs[i+1])
+		}
+	}
+}
+
+func TestNewWrapper_ClientServerRequiresServerURL(t *testing.T) {
+	_, err := NewWrapper(etc.Trivy{Mode: etc.ModeClientServer})
+	assert.Error(t, err)
+}
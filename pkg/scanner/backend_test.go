@@ -0,0 +1,30 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubBackend struct{}
+
+func (stubBackend) Run(imageRef string, auth Auth, opts Options) (Report, error) {
+	return Report{Target: imageRef}, nil
+}
+
+func TestRegisterAndNew(t *testing.T) {
+	Register("stub", func() (Backend, error) { return stubBackend{}, nil })
+
+	backend, err := New("stub")
+	require.NoError(t, err)
+
+	report, err := backend.Run("alpine:3.10", Auth{}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "alpine:3.10", report.Target)
+}
+
+func TestNew_UnknownBackend(t *testing.T) {
+	_, err := New("does-not-exist")
+	assert.Error(t, err)
+}
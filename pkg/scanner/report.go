@@ -0,0 +1,33 @@
+package scanner
+
+// Report is the Harbor-native vulnerability scan result produced by every
+// Backend implementation.
+type Report struct {
+	Target          string
+	Vulnerabilities []Vulnerability
+}
+
+// Vulnerability is a single finding in a Report.
+type Vulnerability struct {
+	VulnerabilityID  string
+	PkgName          string
+	InstalledVersion string
+	FixedVersion     string
+	Severity         string
+	Title            string
+	Description      string
+}
+
+// Auth wraps the registry credentials passed to a Backend.
+type Auth struct {
+	Username string
+	Password string
+}
+
+// Options carries the scan knobs that vary per request rather than per
+// deployment, on top of whatever a Backend was constructed with.
+type Options struct {
+	Severity      string
+	VulnType      string
+	IgnoreUnfixed bool
+}